@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -10,10 +11,14 @@ import (
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/polyverse/disasm"
 	"github.com/polyverse/masche/memaccess"
 	"github.com/polyverse/ropoly/lib"
+	"github.com/polyverse/ropoly/lib/architectures"
+	"github.com/polyverse/ropoly/lib/fpstore"
 	"github.com/polyverse/ropoly/lib/types"
 	log "github.com/sirupsen/logrus"
+	"time"
 )
 
 const indent string = "    "
@@ -49,6 +54,42 @@ func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode("Ropoly API Healthy")
 } // ROPTestHandler()
 
+// wantsNDJSON reports whether the caller asked for the streaming
+// newline-delimited JSON response (one gadget/region per line) instead of
+// the default pretty-printed array, via `Accept: application/x-ndjson` or
+// `?stream=1`.
+func wantsNDJSON(r *http.Request) bool {
+	if r.Form.Get("stream") == "1" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
+
+// writeGadgetNDJSON writes a single gadget as one JSON line, flushing
+// immediately so large gadget sets don't have to be fully discovered
+// before the client sees the first result.
+func writeGadgetNDJSON(w http.ResponseWriter, gadget interface{}) error {
+	encodeErr := json.NewEncoder(w).Encode(gadget)
+	if encodeErr != nil {
+		return encodeErr
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// getArch reads an optional arch= override for cross-architecture
+// disassembly of uploaded binaries or inspected processes. An empty
+// result means the caller should auto-detect from the target itself.
+func getArch(r *http.Request) (architectures.Arch, error) {
+	archStr := r.Form.Get("arch")
+	if archStr == "" {
+		return "", nil
+	}
+	return architectures.ParseArch(archStr)
+}
+
 func getFilepath(r *http.Request, uri string) string {
 	splitUri := strings.Split(r.RequestURI, uri)
 	path := strings.SplitN(splitUri[len(splitUri)-1], "?", 2)[0]
@@ -86,6 +127,114 @@ func FileHandler(w http.ResponseWriter, r *http.Request) {
 	} // switch
 }
 
+// CoreHandler dispatches /api/v1/cores/{path} requests, mirroring
+// FileHandler's query= dispatch but over an ELF core dump instead of a
+// live binary, so a crashed or snapshotted process can be inspected
+// without ptrace.
+func CoreHandler(w http.ResponseWriter, r *http.Request) {
+	path := getFilepath(r, "api/v1/cores")
+
+	query := r.FormValue("query")
+	switch query {
+	case "gadgets":
+		GadgetsFromCoreDumpHandler(w, r, path)
+	case "regions":
+		CoreRegionsHandler(w, r, path)
+	case "fingerprint":
+		CoreFingerprintHandler(w, r, path)
+	case "disasm":
+		http.Error(w, fmt.Sprintf("query=%s is not yet implemented for core dumps.", query), http.StatusBadRequest)
+	default:
+		GadgetsFromCoreDumpHandler(w, r, path)
+	} // switch
+}
+
+// CoreRegionsHandler reports the executable segments of the core dump at
+// path, mirroring ROPMemoryRegionsHandler's shape for a live process.
+func CoreRegionsHandler(w http.ResponseWriter, r *http.Request, path string) {
+	regions, err := lib.CoreDumpRegions(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.MarshalIndent(regions, "", indent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// CoreFingerprintHandler fingerprints the core dump at path, the same
+// region-keyed shape Fingerprint produces for a live process, so a
+// crashed or snapshotted process can be run through fingerprint
+// comparisons (e.g. in CI) without ptrace.
+func CoreFingerprintHandler(w http.ResponseWriter, r *http.Request, path string) {
+	var gadgetLen uint64 = 2 // Gadgets longer than 2 instructions must be requested explicitly
+	var err error
+	lenStr := r.Form.Get("len")
+	if lenStr != "" {
+		gadgetLen, err = strconv.ParseUint(lenStr, 0, 32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		} // if
+	} // if
+
+	result, harderror, softerrors := lib.FingerprintCoreDump(path, int(gadgetLen))
+	logErrors(harderror, softerrors)
+	if harderror != nil {
+		http.Error(w, harderror.Error(), http.StatusInternalServerError)
+		return
+	} // if
+
+	b, err := json.MarshalIndent(result, "", indent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} // if
+	w.Write(b)
+}
+
+func GadgetsFromCoreDumpHandler(w http.ResponseWriter, r *http.Request, path string) {
+	var gadgetLen uint64 = 2 // Gadgets longer than 2 instructions must be requested explicitly
+	var err error
+	lenStr := r.Form.Get("len")
+	if lenStr != "" {
+		gadgetLen, err = strconv.ParseUint(lenStr, 0, 32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} // if
+	} // if
+
+	gadgets, harderror, softerrors := lib.GadgetsFromCoreDump(path, int(gadgetLen))
+	logErrors(harderror, softerrors)
+	if harderror != nil {
+		http.Error(w, harderror.Error(), http.StatusInternalServerError)
+		return
+	} // if
+
+	if wantsNDJSON(r) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, gadget := range gadgets {
+			if err := writeGadgetNDJSON(w, gadget); err != nil {
+				log.WithError(err).Error("Unable to write a gadget to the NDJSON stream.")
+				return
+			}
+		}
+		return
+	}
+
+	b, err := json.MarshalIndent(gadgets, "", indent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} // if
+	w.Write(b)
+}
+
 func PidHandler(w http.ResponseWriter, r *http.Request) {
 	pid, err := getPid(r)
 	if err != nil {
@@ -109,6 +258,8 @@ func PidHandler(w http.ResponseWriter, r *http.Request) {
 		ROPMemoryRegionsHandler(w, r)
 	case "region-fingerprints":
 		RegionFingerprintsHandler(w, r, int(pid))
+	case "libraries":
+		PidLibrariesHandler(w, r, int(pid))
 	default:
 		PolyverseTaintedPidHandler(w, r, int(pid))
 	}
@@ -193,6 +344,17 @@ func GadgetsFromFileHandler(w http.ResponseWriter, r *http.Request, path string)
 		return
 	} // if
 
+	if wantsNDJSON(r) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, gadget := range gadgets {
+			if err := writeGadgetNDJSON(w, gadget); err != nil {
+				log.WithError(err).Error("Unable to write a gadget to the NDJSON stream.")
+				return
+			}
+		}
+		return
+	}
+
 	b, err := json.MarshalIndent(gadgets, "", indent)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -224,7 +386,13 @@ func FileDisasmHandler(w http.ResponseWriter, r *http.Request, path string) {
 		}
 	} // if
 
-	instructions, harderror, softerrors := lib.DisassembleFile(path, types.Addr(start), types.Addr(end))
+	arch, err := getArch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	instructions, harderror, softerrors := lib.DisassembleFile(path, types.Addr(start), types.Addr(end), arch)
 	logErrors(harderror, softerrors)
 	if harderror != nil {
 		http.Error(w, harderror.Error(), http.StatusInternalServerError)
@@ -262,7 +430,13 @@ func ProcessDisasmHandler(w http.ResponseWriter, r *http.Request, pid int) {
 		}
 	} // if
 
-	instructions, harderror, softerrors := lib.DisassembleProcess(pid, types.Addr(start), types.Addr(end))
+	arch, err := getArch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	instructions, harderror, softerrors := lib.DisassembleProcess(pid, types.Addr(start), types.Addr(end), arch)
 	logErrors(harderror, softerrors)
 	if harderror != nil {
 		http.Error(w, harderror.Error(), http.StatusInternalServerError)
@@ -278,17 +452,14 @@ func ProcessDisasmHandler(w http.ResponseWriter, r *http.Request, pid int) {
 }
 
 func FileGadgetSearchHandler(w http.ResponseWriter, r *http.Request, path string) {
-	search := r.Form.Get("string")
-	if search == "" {
-		search = r.Form.Get("regexp")
-		if search == "" {
-			err := errors.New("Search with no or empty target given.")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	} // if
+	spec, matcher, err := parseGadgetSearchRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	spec.Path = path
 
-	http.Error(w, "This functionality is not yet implemented.", http.StatusNotImplemented)
+	runGadgetSearch(w, spec, matcher)
 }
 
 func PidListingHandler(w http.ResponseWriter, r *http.Request) {
@@ -364,16 +535,120 @@ func GadgetsFromPidHandler(w http.ResponseWriter, r *http.Request, pid int) {
 		} // if
 	} // else if
 
+	arch, err := getArch(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if wantsNDJSON(r) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		sink := lib.GadgetSinkFunc(func(gadget *disasm.Gadget) error {
+			return writeGadgetNDJSON(w, gadget)
+		})
+		harderror, softerrors := lib.GadgetsFromProcessToSink(pid, int(gadgetLen),
+			types.Addr(start), types.Addr(end), types.Addr(base), arch, sink)
+		logErrors(harderror, softerrors)
+		return
+	}
+
 	gadgets, harderror, softerrors := lib.GadgetsFromProcess(pid, int(gadgetLen),
-		types.Addr(start), types.Addr(end), types.Addr(base))
+		types.Addr(start), types.Addr(end), types.Addr(base), arch)
 	logErrors(harderror, softerrors)
+
+	b, err := json.MarshalIndent(gadgets, "", indent)
 	if err != nil {
-		logErrors(err, softerrors)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
-	} // if
+	}
+	w.Write(b)
+}
 
-	b, err := json.MarshalIndent(gadgets, "", indent)
+// parseFingerprintRequest reads the len=/start=/end=/base=/arch= params
+// shared by FingerprintForFileHandler and FingerprintForPidHandler.
+func parseFingerprintRequest(r *http.Request) (lib.GadgetSearchSpec, error) {
+	spec := lib.GadgetSearchSpec{}
+
+	var gadgetLen uint64 = 2 // Gadgets longer than 2 instructions must be requested explicitly
+	if lenStr := r.Form.Get("len"); lenStr != "" {
+		var err error
+		gadgetLen, err = strconv.ParseUint(lenStr, 0, 32)
+		if err != nil {
+			return spec, err
+		}
+	}
+	spec.MaxLength = int(gadgetLen)
+
+	var start uint64 = defaultStart
+	if startStr := r.Form.Get("start"); startStr != "" {
+		var err error
+		start, err = strconv.ParseUint(startStr, 0, 64)
+		if err != nil {
+			return spec, err
+		}
+	}
+	spec.Start = types.Addr(start)
+
+	var end uint64 = defaultEnd
+	if endStr := r.Form.Get("end"); endStr != "" {
+		var err error
+		end, err = strconv.ParseUint(endStr, 0, 64)
+		if err != nil {
+			return spec, err
+		}
+	}
+	spec.End = types.Addr(end)
+
+	var base uint64 = defaultStart
+	if baseStr := r.Form.Get("base"); baseStr != "" {
+		var err error
+		base, err = strconv.ParseUint(baseStr, 0, 64)
+		if err != nil {
+			return spec, err
+		}
+	}
+	spec.Base = types.Addr(base)
+
+	arch, err := getArch(r)
+	if err != nil {
+		return spec, err
+	}
+	spec.Arch = arch
+
+	return spec, nil
+}
+
+// runFingerprint executes spec's fingerprint walk and writes the result:
+// streamed as one NDJSON line per region when the caller asked for it via
+// wantsNDJSON (the same opt-in the gadget handlers honor), so a process
+// with a lot of executable regions doesn't have to be held fully in
+// memory before the first region reaches the client, or as a single
+// pretty-printed map otherwise.
+func runFingerprint(w http.ResponseWriter, r *http.Request, spec lib.GadgetSearchSpec) {
+	if wantsNDJSON(r) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		sink := lib.FingerprintSinkFunc(func(region memaccess.MemoryRegion, fingerprint *lib.FingerprintRegion) error {
+			if err := lib.WriteFingerprintRegionNDJSON(w, region, fingerprint); err != nil {
+				return err
+			}
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			return nil
+		})
+		harderror, softerrors := lib.FingerprintToSink(spec, sink)
+		logErrors(harderror, softerrors)
+		return
+	}
+
+	result, harderror, softerrors := lib.Fingerprint(spec)
+	logErrors(harderror, softerrors)
+	if harderror != nil {
+		http.Error(w, harderror.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.MarshalIndent(result, "", indent)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -381,14 +656,132 @@ func GadgetsFromPidHandler(w http.ResponseWriter, r *http.Request, pid int) {
 	w.Write(b)
 }
 
+// FingerprintForFileHandler fingerprints the static binary at path,
+// streaming as NDJSON when requested so a large binary's gadget set
+// doesn't have to be held in memory before the first region is written.
+func FingerprintForFileHandler(w http.ResponseWriter, r *http.Request, path string) {
+	spec, err := parseFingerprintRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	spec.Path = path
+
+	runFingerprint(w, r, spec)
+}
+
+// FingerprintForPidHandler fingerprints the live process pid the same way
+// FingerprintForFileHandler does for a static binary. This is the
+// NDJSON-streamable path: without it, fingerprinting a process with many
+// executable regions had to hold the whole result in memory before
+// writing anything, which is what drove OOMs on large targets.
+func FingerprintForPidHandler(w http.ResponseWriter, r *http.Request, pid int) {
+	spec, err := parseFingerprintRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	spec.Pid = pid
+
+	runFingerprint(w, r, spec)
+}
+
+// LibraryTaintEntry pairs one of pid's mapped libraries with whether the
+// host-visible file backing it carries Polyverse taint, resolving through
+// pid's own mount namespace first so a containerized target is checked
+// against the file that's actually mapped, not whatever happens to live
+// at that path on the host.
+type LibraryTaintEntry struct {
+	MappedPath      string `json:"mappedPath"`
+	HostPath        string `json:"hostPath"`
+	PolyverseTained bool   `json:"polyverseTainted"`
+}
+
 func PolyverseTaintedPidHandler(w http.ResponseWriter, r *http.Request, pid int) {
-	libraries, err, softerrors := lib.GetLibrariesForPid(pid, true)
+	pc, err := lib.NewProcessContext(pid)
 	if err != nil {
-		logErrors(err, softerrors)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	regions, harderror, softerrors := lib.ROPMemoryRegions(pid, memaccess.Readable+memaccess.Executable)
+	logErrors(harderror, softerrors)
+	if harderror != nil {
+		http.Error(w, harderror.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	seen := map[string]bool{}
+	libraries := []LibraryTaintEntry{}
+	for _, region := range regions {
+		if region.Kind == "" || seen[region.Kind] {
+			continue
+		}
+		seen[region.Kind] = true
+
+		hostPath := pc.ResolveBackingPath(region.Kind)
+		tainted, err := lib.HasPolyverseTaint(hostPath)
+		if err != nil {
+			log.WithError(err).Warnf("Unable to check Polyverse taint on %s (mapped as %s in Pid %d).", hostPath, region.Kind, pid)
+			continue
+		}
+
+		libraries = append(libraries, LibraryTaintEntry{
+			MappedPath:      region.Kind,
+			HostPath:        hostPath,
+			PolyverseTained: tainted,
+		})
+	}
+
+	b, err := json.MarshalIndent(libraries, "", indent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} // if
+	w.Write(b)
+}
+
+// LibraryEntry pairs a mapped executable region's path as pid itself
+// sees it with the host-visible path to the ELF backing it, so a
+// containerized target's libraries can be re-opened for fingerprinting
+// from outside its mount namespace.
+type LibraryEntry struct {
+	MappedPath string `json:"mappedPath"`
+	HostPath   string `json:"hostPath"`
+}
+
+// PidLibrariesHandler lists each distinct file backing pid's mapped
+// executable regions, once per mapped path, alongside the host-visible
+// path to that ELF, resolving through pid's own mount namespace so this
+// works for containerized workloads as well as host processes. Regions
+// with no backing path (anonymous mappings) are not listed.
+func PidLibrariesHandler(w http.ResponseWriter, r *http.Request, pid int) {
+	pc, err := lib.NewProcessContext(pid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	regions, harderror, softerrors := lib.ROPMemoryRegions(pid, memaccess.Readable+memaccess.Executable)
+	logErrors(harderror, softerrors)
+	if harderror != nil {
+		http.Error(w, harderror.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	seen := map[string]bool{}
+	libraries := []LibraryEntry{}
+	for _, region := range regions {
+		if region.Kind == "" || seen[region.Kind] {
+			continue
+		}
+		seen[region.Kind] = true
+		libraries = append(libraries, LibraryEntry{
+			MappedPath: region.Kind,
+			HostPath:   pc.ResolveBackingPath(region.Kind),
+		})
+	}
+
 	b, err := json.MarshalIndent(libraries, "", indent)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -398,17 +791,84 @@ func PolyverseTaintedPidHandler(w http.ResponseWriter, r *http.Request, pid int)
 }
 
 func PidGadgetSearchHandler(w http.ResponseWriter, r *http.Request, pid int) {
-	search := r.Form.Get("string")
-	if search == "" {
-		search = r.Form.Get("regexp")
-		if search == "" {
-			err := errors.New("Search with no or empty target given.")
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	spec, matcher, err := parseGadgetSearchRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	spec.Pid = pid
+
+	runGadgetSearch(w, spec, matcher)
+}
+
+// parseGadgetSearchRequest reads the string=/regexp= target, the case=,
+// minlen=/maxlen= and offset=/limit= paging parameters shared by
+// FileGadgetSearchHandler and PidGadgetSearchHandler.
+func parseGadgetSearchRequest(r *http.Request) (lib.GadgetSearchSpec, lib.Matcher, error) {
+	spec := lib.GadgetSearchSpec{}
+
+	caseInsensitive := strings.ToLower(r.Form.Get("case")) == "insensitive"
+
+	var matcher lib.Matcher
+	if target := r.Form.Get("string"); target != "" {
+		matcher = lib.NewStringMatcher(target, !caseInsensitive)
+	} else if pattern := r.Form.Get("regexp"); pattern != "" {
+		var err error
+		matcher, err = lib.NewRegexpMatcher(pattern, caseInsensitive)
+		if err != nil {
+			return spec, nil, errors.New("Unable to compile regexp: " + err.Error())
 		}
+	} else {
+		return spec, nil, errors.New("Search with no or empty target given.")
+	}
+
+	var err error
+	if minLenStr := r.Form.Get("minlen"); minLenStr != "" {
+		spec.MinLen, err = strconv.Atoi(minLenStr)
+		if err != nil {
+			return spec, nil, err
+		}
+	}
+	if maxLenStr := r.Form.Get("maxlen"); maxLenStr != "" {
+		spec.MaxLen, err = strconv.Atoi(maxLenStr)
+		if err != nil {
+			return spec, nil, err
+		}
+	}
+	// spec.MaxLength (gadget generation length) is left at its zero value
+	// here; SearchGadgets derives it from MinLen/MaxLen itself so that a
+	// minlen-only search still generates gadgets long enough to match.
+
+	if offsetStr := r.Form.Get("offset"); offsetStr != "" {
+		spec.Offset, err = strconv.Atoi(offsetStr)
+		if err != nil {
+			return spec, nil, err
+		}
+	}
+	if limitStr := r.Form.Get("limit"); limitStr != "" {
+		spec.Limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			return spec, nil, err
+		}
+	}
+
+	return spec, matcher, nil
+}
+
+func runGadgetSearch(w http.ResponseWriter, spec lib.GadgetSearchSpec, matcher lib.Matcher) {
+	hits, harderror, softerrors := lib.SearchGadgets(spec, matcher)
+	logErrors(harderror, softerrors)
+	if harderror != nil {
+		http.Error(w, harderror.Error(), http.StatusInternalServerError)
+		return
 	} // if
 
-	http.Error(w, "This functionality is not yet implemented.", http.StatusNotImplemented)
+	b, err := json.MarshalIndent(hits, "", indent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} // if
+	w.Write(b)
 }
 
 func ROPMemoryRegionsHandler(w http.ResponseWriter, r *http.Request) {
@@ -468,4 +928,111 @@ func ROPMemoryRegionsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	} // if
 	w.Write(b)
-} // ROPMemoryRegionsHandler()
\ No newline at end of file
+} // ROPMemoryRegionsHandler()
+
+// fpStore is the persistent fingerprint store backing the
+// /api/v1/fingerprints routes. It's nil until InitFingerprintStore is
+// called, the same way FileSystemRoot is set up by whatever wires these
+// handlers into a router.
+var fpStore *fpstore.Store
+
+// InitFingerprintStore opens (creating if necessary) a BoltDB-backed
+// fingerprint store at path and makes it available to the
+// /api/v1/fingerprints handlers below.
+func InitFingerprintStore(path string) error {
+	store, err := fpstore.NewBoltStore(path)
+	if err != nil {
+		return err
+	}
+	fpStore = store
+	return nil
+}
+
+// FingerprintSnapshotHandler handles POST /api/v1/fingerprints, taking a
+// fingerprint of a file or live PID and persisting it for later retrieval
+// and diffing.
+func FingerprintSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if fpStore == nil {
+		http.Error(w, "The fingerprint store has not been initialized.", http.StatusInternalServerError)
+		return
+	}
+
+	spec := lib.GadgetSearchSpec{Path: r.Form.Get("path")}
+	target := spec.Path
+	if spec.Path == "" {
+		pid, err := getPid(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		spec.Pid = int(pid)
+		target = strconv.FormatUint(pid, 10)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	snap, err := fpStore.Snapshot(spec, host, target, time.Now().Unix())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.MarshalIndent(snap, "", indent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// FingerprintByIDHandler handles GET /api/v1/fingerprints/{id}, returning
+// a previously persisted snapshot.
+func FingerprintByIDHandler(w http.ResponseWriter, r *http.Request) {
+	if fpStore == nil {
+		http.Error(w, "The fingerprint store has not been initialized.", http.StatusInternalServerError)
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	snap, err := fpStore.Get(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	b, err := json.MarshalIndent(snap, "", indent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// FingerprintDiffHandler handles GET /api/v1/fingerprints/{a}/diff/{b},
+// returning the FingerprintComparison between two persisted snapshots.
+// Regions whose gadget signatures are identical between a and b are
+// reported with only a Displacement, skipping the full per-gadget
+// comparison; see lib.compareFingerprintRegions.
+func FingerprintDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if fpStore == nil {
+		http.Error(w, "The fingerprint store has not been initialized.", http.StatusInternalServerError)
+		return
+	}
+
+	vars := mux.Vars(r)
+	comparison, err := fpStore.Diff(vars["a"], vars["b"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	b, err := json.MarshalIndent(comparison, "", indent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
\ No newline at end of file
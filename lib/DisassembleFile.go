@@ -1,10 +1,23 @@
 package lib
 
 import (
+	"github.com/polyverse/ropoly/lib/architectures"
 	"github.com/polyverse/ropoly/lib/types"
 )
 
-func DisassembleFile(path string, start types.Addr, end types.Addr) ([]*types.InstructionInstance, error, []error) {
+// DisassembleFile disassembles every executable section of the binary at
+// path between start and end. arch overrides the architecture that would
+// otherwise be auto-detected from the binary's own header (ELF e_machine
+// or Mach-O CPU type); pass "" to always auto-detect.
+func DisassembleFile(path string, start types.Addr, end types.Addr, arch architectures.Arch) ([]*types.InstructionInstance, error, []error) {
+	if arch == "" {
+		detected, err := architectures.DetectFile(path)
+		if err != nil {
+			return nil, err, nil
+		}
+		arch = detected
+	}
+
 	b, _, err := openBinary(path)
 	if err != nil {
 		return nil, err, nil
@@ -18,7 +31,7 @@ func DisassembleFile(path string, start types.Addr, end types.Addr) ([]*types.In
 		if err != nil {
 			return nil, err, nil
 		}
-		instructions, errors := Disasm(progData, addr, start, end)
+		instructions, errors := Disasm(progData, addr, start, end, arch)
 		softerrs = append(softerrs, errors...)
 		allInstructions = append(allInstructions, instructions...)
 		sectionExists, addr, progData, err = b.nextSectionData()
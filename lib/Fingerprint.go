@@ -1,25 +1,66 @@
 package lib
 
 import (
+	"crypto/sha256"
+	"sort"
+	"strings"
+
 	"github.com/polyverse/disasm"
 	"github.com/polyverse/masche/memaccess"
 )
 
+// Fingerprint builds the full FingerprintResult for spec in memory. For a
+// large target, prefer FingerprintToSink, which hands each region's
+// fingerprint to a FingerprintSink (e.g. an NDJSON writer) as soon as it's
+// complete, instead of holding every region until the whole target has
+// been walked.
 func Fingerprint(spec GadgetSearchSpec) (FingerprintResult, error, []error) {
-	fingerprint := map[string]*FingerprintRegion{}
+	sink := &sliceFingerprintSink{}
+	harderror, softerrors := FingerprintToSink(spec, sink)
+	return FingerprintResult{sink.regions}, harderror, softerrors
+}
+
+// FingerprintToSink walks spec's gadgets the same way Fingerprint does,
+// but emits each region's FingerprintRegion to sink as soon as the walk
+// moves on to the next region, rather than accumulating every region into
+// one in-memory map.
+func FingerprintToSink(spec GadgetSearchSpec, sink FingerprintSink) (error, []error) {
 	var section memaccess.MemoryRegion
+	var current *FingerprintRegion
+	var sinkErr error
+
+	flush := func() {
+		if current == nil || sinkErr != nil {
+			return
+		}
+		sinkErr = sink.EmitRegion(section, current)
+	}
+
 	harderror, softerrors := OperateOnGadgets(spec, func(region memaccess.MemoryRegion) {
-		section = region
+		if region != section {
+			flush()
+			section = region
+			current = nil
+		}
 	}, func(gadget Gadget) {
-		if (fingerprint[section.Kind]) == nil {
-			fingerprint[section.Kind] = new(FingerprintRegion)
-			fingerprint[section.Kind].Region = section
-			fingerprint[section.Kind].Gadgets = map[Sig][]disasm.Ptr{}
+		if current == nil {
+			current = &FingerprintRegion{Region: section, Gadgets: map[Sig][]disasm.Ptr{}}
 		}
-		fingerprint[section.Kind].Gadgets[gadget.Signature] = append(fingerprint[section.Kind].Gadgets[gadget.Signature], gadget.Address)
+		current.Gadgets[gadget.Signature] = append(current.Gadgets[gadget.Signature], gadget.Address)
 	})
+	flush()
+
+	if harderror == nil && sinkErr != nil {
+		harderror = sinkErr
+	}
+	return harderror, softerrors
+}
 
-	return FingerprintResult{fingerprint}, harderror, softerrors
+// CompareFingerprintRegions exposes compareFingerprints to other packages
+// (fpstore, in particular) that diff fingerprints built from persisted
+// region maps rather than a freshly computed FingerprintResult.
+func CompareFingerprintRegions(old, new map[string]*FingerprintRegion) FingerprintComparison {
+	return compareFingerprints(old, new)
 }
 
 func compareFingerprints(old, new map[string]*FingerprintRegion) FingerprintComparison {
@@ -41,12 +82,38 @@ func compareFingerprints(old, new map[string]*FingerprintRegion) FingerprintComp
 	return ret
 }
 
+// regionSignatureHash hashes a region's sorted gadget signatures, so two
+// regions that contain exactly the same gadgets hash identically
+// regardless of map iteration order.
+func regionSignatureHash(region FingerprintRegion) [sha256.Size]byte {
+	sigs := make([]string, 0, len(region.Gadgets))
+	for sig := range region.Gadgets {
+		sigs = append(sigs, string(sig))
+	}
+	sort.Strings(sigs)
+	return sha256.Sum256([]byte(strings.Join(sigs, ",")))
+}
+
 func compareFingerprintRegions(old FingerprintRegion, new FingerprintRegion) FingerprintRegionComparison {
-	ret := FingerprintRegionComparison {
-		Region: old.Region,
-		Displacement: uint64(new.Region.Address - old.Region.Address),
+	displacement := uint64(new.Region.Address - old.Region.Address)
+
+	// Snapshots of the same target taken close together are usually
+	// byte-identical region for region; when the sorted signature sets
+	// match, skip the O(n*m) per-gadget displacement loop below entirely
+	// and report only the uniform region displacement. This is what
+	// turns repeated polling comparisons from O(n*m) into near-linear.
+	if regionSignatureHash(old) == regionSignatureHash(new) {
+		return FingerprintRegionComparison{
+			Region:       old.Region,
+			Displacement: displacement,
+		}
+	}
+
+	ret := FingerprintRegionComparison{
+		Region:              old.Region,
+		Displacement:        displacement,
 		GadgetDisplacements: map[disasm.Ptr][]uint64{},
-		AddedGadgets: map[Sig][]disasm.Ptr{},
+		AddedGadgets:        map[Sig][]disasm.Ptr{},
 	}
 
 	for sig, addresses := range old.Gadgets {
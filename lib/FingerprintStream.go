@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/polyverse/masche/memaccess"
+)
+
+// fingerprintStreamRecord is the on-the-wire shape written by the NDJSON
+// fingerprint handlers: one line per region, region and fingerprint kept
+// side by side the same way FingerprintSink receives them.
+type fingerprintStreamRecord struct {
+	Region      memaccess.MemoryRegion `json:"region"`
+	Fingerprint *FingerprintRegion     `json:"fingerprint"`
+}
+
+// WriteFingerprintRegionNDJSON writes a single region's fingerprint as one
+// JSON line, for use as the body of a FingerprintSink backing an NDJSON
+// HTTP response.
+func WriteFingerprintRegionNDJSON(w io.Writer, region memaccess.MemoryRegion, fingerprint *FingerprintRegion) error {
+	return json.NewEncoder(w).Encode(fingerprintStreamRecord{Region: region, Fingerprint: fingerprint})
+}
+
+// ReadFingerprintRegionsNDJSON parses an NDJSON stream previously written
+// by WriteFingerprintRegionNDJSON (e.g. a snapshot saved to disk) back
+// into the map[string]*FingerprintRegion shape compareFingerprints
+// expects, without requiring the whole snapshot to be held as one JSON
+// array first.
+func ReadFingerprintRegionsNDJSON(r io.Reader) (map[string]*FingerprintRegion, error) {
+	regions := map[string]*FingerprintRegion{}
+
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var record fingerprintStreamRecord
+		if err := decoder.Decode(&record); err != nil {
+			return nil, errors.Wrapf(err, "Unable to decode a fingerprint NDJSON record.")
+		}
+		if existing := regions[record.Region.Kind]; existing != nil {
+			MergeFingerprintRegion(existing, record.Fingerprint)
+		} else {
+			regions[record.Region.Kind] = record.Fingerprint
+		}
+	}
+
+	return regions, nil
+}
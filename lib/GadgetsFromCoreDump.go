@@ -0,0 +1,267 @@
+package lib
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/polyverse/disasm"
+	"github.com/polyverse/masche/memaccess"
+)
+
+// noteTypeFile is NT_FILE, the core note type the kernel uses to record
+// which file backs each mapped region of a dumped process.
+const noteTypeFile = 0x46494c45
+
+// GadgetsFromCoreDump parses the ELF core file at path and returns every
+// gadget up to maxLength instructions found in its executable PT_LOAD
+// segments. Each gadget's Region is set to the host path of the file that
+// backed its segment at the time of the dump (resolved via the core's
+// NT_FILE note), the same way Fingerprint expects region Kind to be
+// populated for a live process. This lets a crashed or snapshotted
+// process be fingerprinted offline, without ptrace.
+func GadgetsFromCoreDump(path string, maxLength int) ([]*disasm.Gadget, error, []error) {
+	core, err := elf.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open %s as an ELF core file.", path), nil
+	}
+	defer core.Close()
+
+	if core.Type != elf.ET_CORE {
+		return nil, fmt.Errorf("%s is not an ELF core file (e_type is %s).", path, core.Type), nil
+	}
+
+	fileMappings, err := coreFileMappingsFrom(core)
+	if err != nil {
+		return nil, err, nil
+	}
+
+	softerrors := []error{}
+	allGadgets := []*disasm.Gadget{}
+
+	for _, prog := range core.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Flags&elf.PF_X == 0 {
+			continue
+		}
+
+		segment := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(segment, 0); err != nil {
+			softerrors = append(softerrors, errors.Wrapf(err, "Unable to read PT_LOAD segment at core offset %d.", prog.Off))
+			continue
+		}
+
+		backingPath, _ := fileMappings.lookup(prog.Vaddr)
+
+		info := disasm.InfoInitBytes(disasm.Ptr(prog.Vaddr), disasm.Ptr(prog.Vaddr+uint64(len(segment))-1), segment)
+		gadgets, errs := info.GetAllGadgets(2, maxLength, 0, 100)
+		for _, gadget := range gadgets {
+			gadget.Region = backingPath
+		}
+		allGadgets = append(allGadgets, gadgets...)
+		softerrors = append(softerrors, errs...)
+	}
+
+	return allGadgets, nil, softerrors
+}
+
+// FingerprintCoreDump fingerprints the ELF core file at path the same way
+// Fingerprint does for a live process: it groups the gadgets
+// GadgetsFromCoreDump finds by the backing file each one's segment was
+// resolved to, so a crashed or snapshotted process can be fingerprinted
+// offline and compared against a baseline (e.g. in CI) without ptrace.
+func FingerprintCoreDump(path string, maxLength int) (FingerprintResult, error, []error) {
+	gadgets, harderror, softerrors := GadgetsFromCoreDump(path, maxLength)
+	if harderror != nil {
+		return FingerprintResult{}, harderror, softerrors
+	}
+
+	regions := map[string]*FingerprintRegion{}
+	for _, gadget := range gadgets {
+		region := regions[gadget.Region]
+		if region == nil {
+			region = &FingerprintRegion{
+				Region:  memaccess.MemoryRegion{Kind: gadget.Region},
+				Gadgets: map[Sig][]disasm.Ptr{},
+			}
+			regions[gadget.Region] = region
+		}
+		region.Gadgets[gadget.Signature] = append(region.Gadgets[gadget.Signature], gadget.Address)
+	}
+
+	return FingerprintResult{regions}, nil, softerrors
+}
+
+// CoreDumpRegion describes one executable segment of a core dump, the
+// same information ROPMemoryRegionsHandler reports for a live process's
+// executable mappings.
+type CoreDumpRegion struct {
+	Address uint64 `json:"address"`
+	Size    uint64 `json:"size"`
+	Path    string `json:"path"`
+}
+
+// CoreDumpRegions parses the ELF core file at path and returns its
+// executable PT_LOAD segments, each resolved back to the host path that
+// backed it at dump time via the core's NT_FILE note.
+func CoreDumpRegions(path string) ([]CoreDumpRegion, error) {
+	core, err := elf.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open %s as an ELF core file.", path)
+	}
+	defer core.Close()
+
+	if core.Type != elf.ET_CORE {
+		return nil, fmt.Errorf("%s is not an ELF core file (e_type is %s).", path, core.Type)
+	}
+
+	fileMappings, err := coreFileMappingsFrom(core)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []CoreDumpRegion
+	for _, prog := range core.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Flags&elf.PF_X == 0 {
+			continue
+		}
+
+		backingPath, _ := fileMappings.lookup(prog.Vaddr)
+		regions = append(regions, CoreDumpRegion{
+			Address: prog.Vaddr,
+			Size:    prog.Filesz,
+			Path:    backingPath,
+		})
+	}
+
+	return regions, nil
+}
+
+// coreFileMapping records one entry of a core's NT_FILE note: the
+// [start, end) virtual address range a file was mapped at, the byte
+// offset into that file the mapping started at, and the file's own path
+// at the time of the dump.
+type coreFileMapping struct {
+	start, end, fileOffset uint64
+	path                   string
+}
+
+type coreFileMappings []coreFileMapping
+
+// lookup returns the backing path and file offset for the mapping that
+// contains vaddr, or ("", 0) if vaddr isn't covered by any NT_FILE entry.
+func (m coreFileMappings) lookup(vaddr uint64) (string, uint64) {
+	for _, mapping := range m {
+		if vaddr >= mapping.start && vaddr < mapping.end {
+			return mapping.path, mapping.fileOffset + (vaddr - mapping.start)
+		}
+	}
+	return "", 0
+}
+
+// coreFileMappingsFrom scans core's PT_NOTE segments for the NT_FILE note
+// and decodes it into the mapping table used to resolve gadget regions
+// back to their backing files.
+func coreFileMappingsFrom(core *elf.File) (coreFileMappings, error) {
+	for _, prog := range core.Progs {
+		if prog.Type != elf.PT_NOTE {
+			continue
+		}
+
+		data := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(data, 0); err != nil {
+			return nil, errors.Wrapf(err, "Unable to read a PT_NOTE segment.")
+		}
+
+		mappings, err := parseNTFileNotes(data, core.ByteOrder)
+		if err != nil {
+			return nil, err
+		}
+		if mappings != nil {
+			return mappings, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// align4 rounds n up to the next 4-byte boundary, the padding every ELF
+// note's name and descriptor fields are aligned to.
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+func parseNTFileNotes(data []byte, order binary.ByteOrder) (coreFileMappings, error) {
+	for len(data) >= 12 {
+		nameSize := order.Uint32(data[0:4])
+		descSize := order.Uint32(data[4:8])
+		noteType := order.Uint32(data[8:12])
+		data = data[12:]
+
+		nameLen := align4(int(nameSize))
+		if len(data) < nameLen {
+			return nil, errors.New("Core note's name field runs past the end of its PT_NOTE segment.")
+		}
+		data = data[nameLen:]
+
+		descLen := align4(int(descSize))
+		if len(data) < descLen {
+			return nil, errors.New("Core note's descriptor runs past the end of its PT_NOTE segment.")
+		}
+		desc := data[0:descSize]
+		data = data[descLen:]
+
+		if noteType == noteTypeFile {
+			return decodeNTFileDescriptor(desc, order)
+		}
+	}
+
+	return nil, nil
+}
+
+// decodeNTFileDescriptor decodes the body of an NT_FILE note: a
+// (count, page_size) header, followed by count (start, end, file_ofs)
+// triples (file_ofs in units of page_size), followed by the mapped
+// files' NUL-terminated paths in the same order.
+func decodeNTFileDescriptor(desc []byte, order binary.ByteOrder) (coreFileMappings, error) {
+	if len(desc) < 16 {
+		return nil, errors.New("NT_FILE note is too short to hold its header.")
+	}
+	count := order.Uint64(desc[0:8])
+	pageSize := order.Uint64(desc[8:16])
+	desc = desc[16:]
+
+	type rawEntry struct{ start, end, fileOfs uint64 }
+	entries := make([]rawEntry, count)
+	for i := uint64(0); i < count; i++ {
+		if len(desc) < 24 {
+			return nil, errors.New("NT_FILE note is truncated in its mapping table.")
+		}
+		entries[i] = rawEntry{
+			start:   order.Uint64(desc[0:8]),
+			end:     order.Uint64(desc[8:16]),
+			fileOfs: order.Uint64(desc[16:24]),
+		}
+		desc = desc[24:]
+	}
+
+	mappings := make(coreFileMappings, 0, count)
+	for i := uint64(0); i < count; i++ {
+		nul := bytes.IndexByte(desc, 0)
+		if nul < 0 {
+			return nil, errors.New("NT_FILE note's filename table is missing a NUL terminator.")
+		}
+		path := string(desc[0:nul])
+		desc = desc[nul+1:]
+
+		mappings = append(mappings, coreFileMapping{
+			start:      entries[i].start,
+			end:        entries[i].end,
+			fileOffset: entries[i].fileOfs * pageSize,
+			path:       path,
+		})
+	}
+
+	return mappings, nil
+}
@@ -5,19 +5,51 @@ import (
 	"github.com/polyverse/disasm"
 	"github.com/polyverse/masche/memaccess"
 	"github.com/polyverse/masche/process"
+	"github.com/polyverse/ropoly/lib/architectures"
+	"github.com/polyverse/ropoly/lib/architectures/arm64"
+	"github.com/polyverse/ropoly/lib/types"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"syscall"
 )
 
-func GadgetsFromProcess(pid int, maxLength int) ([]*disasm.Gadget, error, []error) {
+// GadgetsFromProcess walks the executable memory regions of pid between
+// start and end (offset by base), disassembling each for gadgets up to
+// maxLength instructions long. arch overrides the architecture that
+// would otherwise be auto-detected from the target's own executable via
+// /proc/<pid>/exe; pass "" to always auto-detect.
+//
+// It builds the full result in memory; callers that want to start
+// consuming gadgets as they're found, without waiting on the whole
+// target to be walked, should use GadgetsFromProcessToSink instead.
+func GadgetsFromProcess(pid int, maxLength int, start types.Addr, end types.Addr, base types.Addr, arch architectures.Arch) ([]*disasm.Gadget, error, []error) {
+	sink := &sliceGadgetSink{}
+	harderror, softerrors := GadgetsFromProcessToSink(pid, maxLength, start, end, base, arch, sink)
+	return sink.gadgets, harderror, softerrors
+}
+
+// GadgetsFromProcessToSink does the same walk as GadgetsFromProcess, but
+// emits each gadget to sink as soon as it's decoded instead of
+// accumulating them, so a caller streaming the response (see the
+// ?stream=1 / Accept: application/x-ndjson path in the gadget handlers)
+// never has to hold more than one region's worth of gadgets at a time.
+func GadgetsFromProcessToSink(pid int, maxLength int, start types.Addr, end types.Addr, base types.Addr, arch architectures.Arch, sink GadgetSink) (error, []error) {
+
+	if arch == "" {
+		detected, detectErr := architectures.DetectProcess(pid)
+		if detectErr != nil {
+			log.WithError(detectErr).Warnf("Unable to auto-detect the architecture of Pid %d; falling back to amd64.", pid)
+			detected = architectures.AMD64
+		}
+		arch = detected
+	}
 
 	if pid != os.Getpid() {
 		log.Debugf("Since the Pid for gadget-finding %d is not the same as current pid %d, "+
 			"attempting to PtraceAttach to it, so we can read its memory.", os.Getpid(), pid)
 		err := syscall.PtraceAttach(pid)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Error when attempting to PtraceAttach to Pid %d from Ropoly.", pid), nil
+			return errors.Wrapf(err, "Error when attempting to PtraceAttach to Pid %d from Ropoly.", pid), nil
 		}
 
 		defer func(clearpid int) {
@@ -33,40 +65,107 @@ func GadgetsFromProcess(pid int, maxLength int) ([]*disasm.Gadget, error, []erro
 	softerrors := []error{}
 	proc := process.LinuxProcess(pid)
 
-	allGadgets := []*disasm.Gadget{}
-
-	pc := uintptr(0)
+	pc := uintptr(start)
 	for {
 		region, harderror2, softerrors2 := memaccess.NextMemoryRegionAccess(proc, uintptr(pc), memaccess.Readable+memaccess.Executable)
 		softerrors = append(softerrors, softerrors2...)
 		if harderror2 != nil {
-			return nil, errors.Wrapf(harderror2, "Error when attempting to access the next memory region for Pid %d.", pid), softerrors
+			return errors.Wrapf(harderror2, "Error when attempting to access the next memory region for Pid %d.", pid), softerrors
 		}
 		log.Debugf("Under Pid %d, Found executable memory region %+v", pid, region)
 
 		if region == memaccess.NoRegionAvailable {
 			break
 		}
+		if end != 0 && uintptr(region.Address) >= uintptr(end) {
+			break
+		}
 
 		//Make sure we move the Program Counter
 		pc = region.Address + uintptr(region.Size)
 
-		var info disasm.Info
-		if pid == 0 {
-			info = disasm.InfoInit(disasm.Ptr(region.Address), disasm.Ptr(region.Address+uintptr(region.Size)-1))
-		} else {
+		var regionGadgets []*disasm.Gadget
+		if arch == architectures.ARM64 {
 			bytes := make([]byte, region.Size, region.Size)
-			harderr3, softerrors3 := memaccess.CopyMemory(proc, region.Address, bytes)
-			if harderr3 != nil {
-				return nil, errors.Wrapf(harderr3, "Error when attempting to access the memory contents for Pid %d.", pid), softerrors
+			if pid != 0 {
+				harderr3, softerrors3 := memaccess.CopyMemory(proc, region.Address, bytes)
+				if harderr3 != nil {
+					return errors.Wrapf(harderr3, "Error when attempting to access the memory contents for Pid %d.", pid), softerrors
+				}
+				softerrors = append(softerrors, softerrors3...)
+			}
+
+			regionAddr := types.Addr(uintptr(region.Address)) + base
+			gadgets, errs := arm64.GadgetsFromBytes(bytes, regionAddr, maxLength)
+			regionGadgets = adaptTypesGadgets(gadgets, region.Kind)
+			softerrors = append(softerrors, errs...)
+		} else {
+			var info disasm.Info
+			if pid == 0 {
+				// Pid 0 means Ropoly is disassembling its own live
+				// memory directly rather than a byte buffer copied out
+				// of another process, so this address range is also
+				// where the bytes are actually read from. base is
+				// deliberately not applied here, or it would misdirect
+				// the read itself instead of just relocating reported
+				// addresses.
+				info = disasm.InfoInit(disasm.Ptr(region.Address), disasm.Ptr(region.Address+uintptr(region.Size)-1))
+			} else {
+				bytes := make([]byte, region.Size, region.Size)
+				harderr3, softerrors3 := memaccess.CopyMemory(proc, region.Address, bytes)
+				if harderr3 != nil {
+					return errors.Wrapf(harderr3, "Error when attempting to access the memory contents for Pid %d.", pid), softerrors
+				}
+				softerrors = append(softerrors, softerrors3...)
+
+				// bytes was copied from the real region.Address; base
+				// only relocates the addresses GetAllGadgets reports
+				// for them, the same way the arm64 branch above relates
+				// regionAddr to the bytes it decodes.
+				regionAddr := types.Addr(uintptr(region.Address)) + base
+				info = disasm.InfoInitBytes(disasm.Ptr(uintptr(regionAddr)), disasm.Ptr(uintptr(regionAddr)+region.Size-1), bytes)
+			}
+			gadgets, errs := info.GetAllGadgets(2, maxLength, 0, 100)
+			regionGadgets = gadgets
+			softerrors = append(softerrors, errs...)
+		}
+
+		for _, gadget := range regionGadgets {
+			if err := sink.Emit(gadget); err != nil {
+				return errors.Wrapf(err, "Error while emitting a gadget for Pid %d.", pid), softerrors
 			}
-			softerrors = append(softerrors, softerrors3...)
-			info = disasm.InfoInitBytes(disasm.Ptr(region.Address), disasm.Ptr(region.Address+uintptr(region.Size)-1), bytes)
 		}
-		gadgets, errs := info.GetAllGadgets(2, maxLength, 0, 100)
-		allGadgets = append(allGadgets, gadgets...)
-		softerrors = append(softerrors, errs...)
 	}
 
-	return allGadgets, nil, softerrors
+	return nil, softerrors
+}
+
+// adaptTypesGadgets converts the arm64.PositionedGadget values produced by
+// the arm64 decoder into the disasm.Gadget shape the rest of the package
+// (SearchGadgets, Fingerprint) already expects, so callers don't need to
+// know which backend decoded a given region. Each gadget keeps the real
+// address the decoder computed for it rather than the region's base, and
+// is given a signature derived from its own octets so Fingerprint can key
+// on it.
+func adaptTypesGadgets(gadgets []arm64.PositionedGadget, kind string) []*disasm.Gadget {
+	adapted := make([]*disasm.Gadget, 0, len(gadgets))
+	for _, positioned := range gadgets {
+		gadget := positioned.Gadget
+		instructions := make([]*disasm.Instruction, 0, len(gadget))
+		var octets []byte
+		for _, instr := range gadget {
+			instructions = append(instructions, &disasm.Instruction{
+				Octets: instr.Octets,
+				DisAsm: instr.DisAsm,
+			})
+			octets = append(octets, instr.Octets...)
+		}
+		adapted = append(adapted, &disasm.Gadget{
+			Address:      disasm.Ptr(positioned.Address),
+			Region:       kind,
+			Signature:    Sig(octets),
+			Instructions: instructions,
+		})
+	}
+	return adapted
 }
@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// ProcessContext captures the filesystem view a PID sees, so that paths
+// reported by /proc/<pid>/maps -- which are resolved against whatever
+// mount namespace pid lives in, not ours -- can be translated into paths
+// we can actually open from the host. This matters for a process running
+// inside a container on the same host: its PID is visible to us, but its
+// libraries live under a different rootfs.
+type ProcessContext struct {
+	Pid int
+
+	// rootDir is /proc/<pid>/root, which the kernel resolves to pid's
+	// own filesystem root no matter which mount namespace it's in.
+	rootDir string
+
+	// sameMountNs is true when pid shares our mount namespace, so no
+	// path translation is needed at all.
+	sameMountNs bool
+}
+
+// NewProcessContext opens /proc/<pid>/ns/mnt and /proc/<pid>/root for pid
+// and records its container rootfs prefix (if any), ready for
+// ResolveBackingPath to translate paths /proc/<pid>/maps reports.
+func NewProcessContext(pid int) (*ProcessContext, error) {
+	selfNs, err := os.Readlink("/proc/self/ns/mnt")
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read this process's own mount namespace.")
+	}
+
+	pidNs, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/mnt", pid))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read the mount namespace for Pid %d.", pid)
+	}
+
+	rootDir := fmt.Sprintf("/proc/%d/root", pid)
+	if _, err := os.Stat(rootDir); err != nil {
+		return nil, errors.Wrapf(err, "Unable to access the root filesystem for Pid %d.", pid)
+	}
+
+	return &ProcessContext{
+		Pid:         pid,
+		rootDir:     rootDir,
+		sameMountNs: selfNs == pidNs,
+	}, nil
+}
+
+// ResolveBackingPath takes a path as it appears in pid's own
+// /proc/<pid>/maps and returns the host-visible path to the same file, so
+// it can be reopened for file-level fingerprinting or taint-checking.
+func (pc *ProcessContext) ResolveBackingPath(mapPath string) string {
+	if pc.sameMountNs || mapPath == "" {
+		return mapPath
+	}
+	return filepath.Join(pc.rootDir, mapPath)
+}
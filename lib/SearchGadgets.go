@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/polyverse/disasm"
+	"github.com/polyverse/ropoly/lib/architectures"
+	"github.com/polyverse/ropoly/lib/types"
+)
+
+// GadgetSearchSpec describes where SearchGadgets should look for gadgets:
+// a static binary on disk (Path) or a live process (Pid), plus the same
+// start/end/base window accepted by GadgetsFromProcess.
+type GadgetSearchSpec struct {
+	Path      string
+	Pid       int
+	MaxLength int
+	Start     types.Addr
+	End       types.Addr
+	Base      types.Addr
+	MinLen    int
+	MaxLen    int
+	Offset    int
+	Limit     int
+	Arch      architectures.Arch
+}
+
+// Matcher decides whether a gadget's rendered disassembly is a hit.
+type Matcher interface {
+	Match(disAsm string) bool
+}
+
+type stringMatcher struct {
+	target        string
+	caseSensitive bool
+}
+
+// NewStringMatcher returns a Matcher that looks for target as a literal
+// substring of a gadget's disassembly.
+func NewStringMatcher(target string, caseSensitive bool) Matcher {
+	if !caseSensitive {
+		target = strings.ToLower(target)
+	}
+	return &stringMatcher{target: target, caseSensitive: caseSensitive}
+}
+
+func (m *stringMatcher) Match(disAsm string) bool {
+	if !m.caseSensitive {
+		disAsm = strings.ToLower(disAsm)
+	}
+	return strings.Contains(disAsm, m.target)
+}
+
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+// NewRegexpMatcher compiles pattern and returns a Matcher that runs it
+// against a gadget's disassembly.
+func NewRegexpMatcher(pattern string, caseInsensitive bool) (Matcher, error) {
+	if caseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &regexpMatcher{re: re}, nil
+}
+
+func (m *regexpMatcher) Match(disAsm string) bool {
+	return m.re.MatchString(disAsm)
+}
+
+// GadgetSearchHit is a single gadget whose rendered disassembly matched
+// the requested string or regexp.
+type GadgetSearchHit struct {
+	Address disasm.Ptr     `json:"address"`
+	Region  string         `json:"region"`
+	Gadget  *disasm.Gadget `json:"gadget"`
+}
+
+// SearchGadgets scans every gadget reachable from spec (a file or a live
+// PID) and returns the ones whose rendered disassembly matches matcher.
+// MinLen/MaxLen filter by instruction count, and Offset/Limit page the
+// result set so callers receive only one page of hits. The full gadget
+// set of the target is still generated and held in memory while the
+// search runs.
+func SearchGadgets(spec GadgetSearchSpec, matcher Matcher) ([]GadgetSearchHit, error, []error) {
+	// Generation length (how long a gadget the decoders are asked to walk
+	// out to) is independent of MinLen/MaxLen, which only filter the
+	// already-generated gadgets for display. A MinLen-only search still
+	// needs gadgets generated out to at least MinLen instructions, or
+	// nothing that long will ever exist to filter in the first place.
+	maxLength := spec.MaxLength
+	if maxLength == 0 {
+		maxLength = 2
+	}
+	if spec.MinLen > maxLength {
+		maxLength = spec.MinLen
+	}
+	if spec.MaxLen > maxLength {
+		maxLength = spec.MaxLen
+	}
+
+	var gadgets []*disasm.Gadget
+	var harderror error
+	var softerrors []error
+
+	if spec.Path != "" {
+		gadgets, harderror, softerrors = GadgetsFromFile(spec.Path, maxLength)
+	} else {
+		gadgets, harderror, softerrors = GadgetsFromProcess(spec.Pid, maxLength, spec.Start, spec.End, spec.Base, spec.Arch)
+	}
+	if harderror != nil {
+		return nil, harderror, softerrors
+	}
+
+	hits := []GadgetSearchHit{}
+	matched := 0
+	for _, gadget := range gadgets {
+		if spec.MinLen > 0 && len(gadget.Instructions) < spec.MinLen {
+			continue
+		}
+		if spec.MaxLen > 0 && len(gadget.Instructions) > spec.MaxLen {
+			continue
+		}
+
+		if !matcher.Match(gadgetDisAsm(gadget)) {
+			continue
+		}
+
+		if matched < spec.Offset {
+			matched++
+			continue
+		}
+		matched++
+		if spec.Limit > 0 && len(hits) >= spec.Limit {
+			continue
+		}
+
+		hits = append(hits, GadgetSearchHit{
+			Address: gadget.Address,
+			Region:  gadget.Region,
+			Gadget:  gadget,
+		})
+	}
+
+	return hits, nil, softerrors
+}
+
+// gadgetDisAsm renders a gadget's instructions to the same "inst1;inst2;..."
+// form used for display elsewhere, so matchers can search it as one string.
+func gadgetDisAsm(gadget *disasm.Gadget) string {
+	parts := make([]string, 0, len(gadget.Instructions))
+	for _, instr := range gadget.Instructions {
+		parts = append(parts, instr.DisAsm)
+	}
+	return strings.Join(parts, ";")
+}
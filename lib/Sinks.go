@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"github.com/polyverse/disasm"
+	"github.com/polyverse/masche/memaccess"
+)
+
+// GadgetSink receives gadgets one at a time as they're discovered, so a
+// caller can start consuming (or writing to the wire) before the whole
+// target has been walked, instead of waiting on a fully built slice.
+type GadgetSink interface {
+	Emit(gadget *disasm.Gadget) error
+}
+
+// GadgetSinkFunc adapts a plain function to a GadgetSink.
+type GadgetSinkFunc func(gadget *disasm.Gadget) error
+
+func (f GadgetSinkFunc) Emit(gadget *disasm.Gadget) error {
+	return f(gadget)
+}
+
+// sliceGadgetSink collects emitted gadgets into a slice. It backs the
+// slice-returning GadgetsFromProcess so it can be implemented in terms of
+// the sink-based walk without changing its signature.
+type sliceGadgetSink struct {
+	gadgets []*disasm.Gadget
+}
+
+func (s *sliceGadgetSink) Emit(gadget *disasm.Gadget) error {
+	s.gadgets = append(s.gadgets, gadget)
+	return nil
+}
+
+// FingerprintSink receives fingerprint regions one at a time as they're
+// computed, so a snapshot of a large process doesn't have to be held
+// fully in memory before it can be written out.
+type FingerprintSink interface {
+	EmitRegion(region memaccess.MemoryRegion, fingerprint *FingerprintRegion) error
+}
+
+// FingerprintSinkFunc adapts a plain function to a FingerprintSink.
+type FingerprintSinkFunc func(region memaccess.MemoryRegion, fingerprint *FingerprintRegion) error
+
+func (f FingerprintSinkFunc) EmitRegion(region memaccess.MemoryRegion, fingerprint *FingerprintRegion) error {
+	return f(region, fingerprint)
+}
+
+// sliceFingerprintSink collects emitted regions into the same
+// map[string]*FingerprintRegion shape FingerprintResult already returns,
+// so Fingerprint can be implemented in terms of the sink-based walk
+// without changing its return type.
+type sliceFingerprintSink struct {
+	regions map[string]*FingerprintRegion
+}
+
+func (s *sliceFingerprintSink) EmitRegion(region memaccess.MemoryRegion, fingerprint *FingerprintRegion) error {
+	if s.regions == nil {
+		s.regions = map[string]*FingerprintRegion{}
+	}
+	if existing := s.regions[region.Kind]; existing != nil {
+		MergeFingerprintRegion(existing, fingerprint)
+	} else {
+		s.regions[region.Kind] = fingerprint
+	}
+	return nil
+}
+
+// MergeFingerprintRegion folds src's gadgets into dst in place. Multiple
+// memory regions can share a Kind (most notably Kind == "" for
+// anonymous/JIT mappings), so anything that keys one *FingerprintRegion
+// per Kind, like sliceFingerprintSink and fpstore's regionCollector, needs
+// to accumulate into the existing region rather than replace it outright.
+func MergeFingerprintRegion(dst, src *FingerprintRegion) {
+	for sig, addresses := range src.Gadgets {
+		dst.Gadgets[sig] = append(dst.Gadgets[sig], addresses...)
+	}
+}
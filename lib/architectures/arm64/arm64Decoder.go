@@ -0,0 +1,137 @@
+package arm64
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/polyverse/ropoly/lib/types"
+	"golang.org/x/arch/arm64/arm64asm"
+)
+
+// instructionWidth is fixed on AArch64: every instruction, valid or not,
+// occupies exactly 4 bytes.
+const instructionWidth = 4
+
+func InstructionDecoder(opcodes []byte) (instruction *types.Instruction, err error) {
+	if len(opcodes) < instructionWidth {
+		return nil, errors.New("Not enough bytes remaining to hold a full AArch64 instruction.")
+	}
+
+	var inst arm64asm.Inst
+
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("Unable to decode instruction due to disassembler panic: %v", x)
+		}
+	}()
+
+	inst, err = arm64asm.Decode(opcodes[0:instructionWidth])
+	if err != nil {
+		err = errors.Wrapf(err, "Unable to decode instruction.")
+		return
+	}
+
+	instruction = &types.Instruction{
+		Octets: opcodes[0:instructionWidth],
+		DisAsm: inst.String(),
+	}
+	return
+}
+
+func GadgetDecoder(opcodes []byte) (types.Gadget, error) {
+	gadget := types.Gadget{}
+
+	for len(opcodes) >= instructionWidth {
+		instr, err := InstructionDecoder(opcodes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error decoding underlying instruction.")
+		}
+		gadget = append(gadget, instr)
+		opcodes = opcodes[instructionWidth:]
+	}
+	return gadget, nil
+}
+
+// isTerminator reports whether inst ends a gadget: an unconditional or
+// indirect branch (BR, BLR, RET), an exception return (ERET), or a
+// supervisor/hypervisor/secure-monitor call (SVC, HVC, SMC). These are
+// the same instruction classes amd64 gadget discovery treats as
+// terminators (ret/jmp/call), translated to their AArch64 equivalents.
+func isTerminator(inst arm64asm.Inst) bool {
+	switch inst.Op {
+	case arm64asm.BR, arm64asm.BLR, arm64asm.RET, arm64asm.ERET,
+		arm64asm.SVC, arm64asm.HVC, arm64asm.SMC:
+		return true
+	default:
+		return false
+	}
+}
+
+// PositionedGadget pairs a decoded gadget with the address its first
+// instruction starts at, since that address is what ROP discovery
+// actually cares about and a bare types.Gadget doesn't carry it.
+type PositionedGadget struct {
+	Address types.Addr
+	Gadget  types.Gadget
+}
+
+// GadgetsFromBytes walks backwards from every terminator found in opcodes,
+// in fixed 4-byte steps, collecting gadgets up to maxLength instructions
+// long. base is the address opcodes[0] is mapped at, used to turn each
+// gadget's byte offset into its real address. Unlike amd64, where gadgets
+// can start at any byte offset because x86 instructions are variable
+// length, AArch64's fixed alignment means every candidate start is itself
+// instruction-aligned, so no byte-by-byte scan is needed.
+func GadgetsFromBytes(opcodes []byte, base types.Addr, maxLength int) ([]PositionedGadget, []error) {
+	var gadgets []PositionedGadget
+	var softerrors []error
+
+	instructionCount := len(opcodes) / instructionWidth
+	decoded := make([]arm64asm.Inst, instructionCount)
+	valid := make([]bool, instructionCount)
+
+	for i := 0; i < instructionCount; i++ {
+		inst, err := arm64asm.Decode(opcodes[i*instructionWidth : (i+1)*instructionWidth])
+		if err != nil {
+			softerrors = append(softerrors, errors.Wrapf(err, "Unable to decode instruction at offset %d.", i*instructionWidth))
+			continue
+		}
+		decoded[i] = inst
+		valid[i] = true
+	}
+
+	for i := 0; i < instructionCount; i++ {
+		if !valid[i] || !isTerminator(decoded[i]) {
+			continue
+		}
+
+		for length := 1; length <= maxLength && length <= i+1; length++ {
+			start := i - length + 1
+			ok := true
+			for j := start; j < i; j++ {
+				if !valid[j] {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+
+			gadget := types.Gadget{}
+			for j := start; j <= i; j++ {
+				offset := j * instructionWidth
+				gadget = append(gadget, &types.Instruction{
+					Octets: opcodes[offset : offset+instructionWidth],
+					DisAsm: decoded[j].String(),
+				})
+			}
+			gadgets = append(gadgets, PositionedGadget{
+				Address: base + types.Addr(start*instructionWidth),
+				Gadget:  gadget,
+			})
+		}
+	}
+
+	return gadgets, softerrors
+}
@@ -0,0 +1,82 @@
+package architectures
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"fmt"
+	"os"
+)
+
+// Arch identifies one of the instruction-set backends ropoly knows how to
+// decode. Actual gadget discovery for a given Arch lives in
+// lib.GadgetsFromProcessToSink, which branches on Arch directly, since
+// amd64 and arm64 use different walking strategies (disasm.Info vs a
+// fixed-width backwards scan) that don't share a common decoder shape.
+type Arch string
+
+const (
+	AMD64 Arch = "amd64"
+	ARM64 Arch = "arm64"
+)
+
+// DetectFile inspects an ELF or Mach-O binary's header and returns the
+// Arch ropoly should use to decode it.
+func DetectFile(path string) (Arch, error) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		return archFromELFMachine(f.Machine)
+	}
+
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		return archFromMachOCpu(f.Cpu)
+	}
+
+	return "", fmt.Errorf("Unrecognized binary format for %s; expected ELF or Mach-O.", path)
+}
+
+// DetectProcess inspects the backing executable of a live PID via
+// /proc/<pid>/exe and returns the Arch ropoly should use to decode it.
+func DetectProcess(pid int) (Arch, error) {
+	exe := fmt.Sprintf("/proc/%d/exe", pid)
+	if _, err := os.Stat(exe); err != nil {
+		return "", fmt.Errorf("Unable to stat %s to detect its architecture: %s", exe, err)
+	}
+	return DetectFile(exe)
+}
+
+func archFromELFMachine(machine elf.Machine) (Arch, error) {
+	switch machine {
+	case elf.EM_X86_64:
+		return AMD64, nil
+	case elf.EM_AARCH64:
+		return ARM64, nil
+	default:
+		return "", fmt.Errorf("Unsupported ELF machine type %s.", machine)
+	}
+}
+
+func archFromMachOCpu(cpu macho.Cpu) (Arch, error) {
+	switch cpu {
+	case macho.CpuAmd64:
+		return AMD64, nil
+	case macho.CpuArm64:
+		return ARM64, nil
+	default:
+		return "", fmt.Errorf("Unsupported Mach-O CPU type %d.", cpu)
+	}
+}
+
+// ParseArch maps a user-supplied `arch=` query override (e.g. "amd64",
+// "arm64", "aarch64") onto a registered Arch. An empty string is left to
+// the caller to mean "auto-detect".
+func ParseArch(s string) (Arch, error) {
+	switch s {
+	case "amd64", "x86_64", "x86-64":
+		return AMD64, nil
+	case "arm64", "aarch64":
+		return ARM64, nil
+	default:
+		return "", fmt.Errorf("Unknown architecture override %q.", s)
+	}
+}
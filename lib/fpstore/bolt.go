@@ -0,0 +1,85 @@
+package fpstore
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// boltKV adapts a BoltDB database file to the KVStore interface, mapping
+// each fpstore bucket name onto a BoltDB bucket, created lazily on first
+// write.
+type boltKV struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) a BoltDB-backed Store at
+// path. This is fpstore's default backend; swap in any other KVStore
+// implementation via New for Redis, Postgres, etc.
+func NewBoltStore(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to open BoltDB store at %s.", path)
+	}
+
+	return New(&boltKV{db: db}), nil
+}
+
+func (b *boltKV) Put(bucket, key string, value []byte) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(key), value)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write %s/%s to BoltDB.", bucket, key)
+	}
+	return nil
+}
+
+func (b *boltKV) Update(bucket, key string, fn func(existing []byte) ([]byte, error)) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		var existing []byte
+		if v := bkt.Get([]byte(key)); v != nil {
+			existing = append([]byte{}, v...)
+		}
+
+		updated, err := fn(existing)
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(key), updated)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Unable to update %s/%s in BoltDB.", bucket, key)
+	}
+	return nil
+}
+
+func (b *boltKV) Get(bucket, key string) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		if v := bkt.Get([]byte(key)); v != nil {
+			value = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read %s/%s from BoltDB.", bucket, key)
+	}
+	return value, nil
+}
+
+func (b *boltKV) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,203 @@
+// Package fpstore persists Fingerprint results and diffs them
+// incrementally, so drift between two snapshots of the same target can
+// be tracked over time without the client holding JSON blobs itself.
+package fpstore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/polyverse/masche/memaccess"
+	"github.com/polyverse/ropoly/lib"
+)
+
+const (
+	snapshotsBucket = "snapshots"
+	sigIndexBucket  = "sig-index"
+)
+
+// KVStore is the minimal contract fpstore needs from its backing store,
+// so BoltDB (the default, via NewBoltStore) can be swapped for Redis,
+// Postgres, or anything else that can store and fetch opaque blobs by
+// key within a named bucket.
+type KVStore interface {
+	Put(bucket, key string, value []byte) error
+	Get(bucket, key string) ([]byte, error)
+	// Update atomically reads bucket/key, passes its current value
+	// (nil if unset) to fn, and writes back whatever fn returns, all
+	// within a single transaction. Callers that need a read-modify-write
+	// (the signature index's append-if-absent) must use this instead of
+	// a separate Get+Put, which a concurrent writer could race.
+	Update(bucket, key string, fn func(existing []byte) ([]byte, error)) error
+	Close() error
+}
+
+// Snapshot is one stored fingerprint, keyed by (Host, Target, Timestamp)
+// and indexed by every gadget signature it contains.
+type Snapshot struct {
+	ID        string                            `json:"id"`
+	Host      string                            `json:"host"`
+	Target    string                             `json:"target"` // a PID or a file path
+	Timestamp int64                              `json:"timestamp"`
+	Regions   map[string]*lib.FingerprintRegion `json:"regions"`
+}
+
+// Store persists Snapshots and indexes them by gadget signature so
+// "which snapshots contain this gadget" is an O(1) lookup rather than a
+// scan over every stored snapshot.
+type Store struct {
+	kv KVStore
+}
+
+// New wraps an already-open KVStore in a Store.
+func New(kv KVStore) *Store {
+	return &Store{kv: kv}
+}
+
+// regionCollector implements lib.FingerprintSink by gathering every
+// region it's handed into a plain map, the shape Snapshot persists.
+type regionCollector struct {
+	regions map[string]*lib.FingerprintRegion
+}
+
+func (c *regionCollector) EmitRegion(region memaccess.MemoryRegion, fingerprint *lib.FingerprintRegion) error {
+	if c.regions == nil {
+		c.regions = map[string]*lib.FingerprintRegion{}
+	}
+	if existing := c.regions[region.Kind]; existing != nil {
+		lib.MergeFingerprintRegion(existing, fingerprint)
+	} else {
+		c.regions[region.Kind] = fingerprint
+	}
+	return nil
+}
+
+// Snapshot fingerprints spec and persists the result keyed by
+// (host, target, timestamp), indexing every gadget signature it
+// contains. target is a PID or file path, whichever spec describes.
+func (s *Store) Snapshot(spec lib.GadgetSearchSpec, host string, target string, timestamp int64) (*Snapshot, error) {
+	collector := &regionCollector{}
+	harderror, _ := lib.FingerprintToSink(spec, collector)
+	if harderror != nil {
+		return nil, errors.Wrapf(harderror, "Unable to fingerprint %s for snapshotting.", target)
+	}
+
+	snap := &Snapshot{
+		ID:        fmt.Sprintf("%s:%s:%d", host, target, timestamp),
+		Host:      host,
+		Target:    target,
+		Timestamp: timestamp,
+		Regions:   collector.regions,
+	}
+
+	blob, err := json.Marshal(snap)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to serialize snapshot %s.", snap.ID)
+	}
+	if err := s.kv.Put(snapshotsBucket, snap.ID, blob); err != nil {
+		return nil, errors.Wrapf(err, "Unable to persist snapshot %s.", snap.ID)
+	}
+
+	if err := s.indexSignatures(snap); err != nil {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// Get retrieves a previously stored Snapshot by id.
+func (s *Store) Get(id string) (*Snapshot, error) {
+	blob, err := s.kv.Get(snapshotsBucket, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read snapshot %s.", id)
+	}
+	if blob == nil {
+		return nil, fmt.Errorf("No snapshot found with id %q.", id)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(blob, &snap); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse snapshot %s.", id)
+	}
+	return &snap, nil
+}
+
+// Diff compares two stored snapshots the same way Fingerprint's own
+// comparison does, region by region.
+func (s *Store) Diff(aID, bID string) (lib.FingerprintComparison, error) {
+	a, err := s.Get(aID)
+	if err != nil {
+		return lib.FingerprintComparison{}, err
+	}
+	b, err := s.Get(bID)
+	if err != nil {
+		return lib.FingerprintComparison{}, err
+	}
+
+	return lib.CompareFingerprintRegions(a.Regions, b.Regions), nil
+}
+
+// SnapshotsWithSignature returns the IDs of every stored snapshot known
+// to contain sig, via the reverse index maintained by Snapshot.
+func (s *Store) SnapshotsWithSignature(sig lib.Sig) ([]string, error) {
+	blob, err := s.kv.Get(sigIndexBucket, string(sig))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read the signature index for %s.", sig)
+	}
+	if blob == nil {
+		return nil, nil
+	}
+
+	var ids []string
+	if err := json.Unmarshal(blob, &ids); err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse the signature index for %s.", sig)
+	}
+	return ids, nil
+}
+
+func (s *Store) indexSignatures(snap *Snapshot) error {
+	for _, region := range snap.Regions {
+		for sig := range region.Gadgets {
+			if err := s.addToSignatureIndex(sig, snap.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addToSignatureIndex appends snapshotID to sig's entry in the reverse
+// index, if it isn't already there. The read-modify-write happens inside
+// a single KVStore.Update call so two concurrent Snapshot calls indexing
+// the same signature can't both read the old id list and clobber each
+// other's append.
+func (s *Store) addToSignatureIndex(sig lib.Sig, snapshotID string) error {
+	key := string(sig)
+
+	err := s.kv.Update(sigIndexBucket, key, func(existing []byte) ([]byte, error) {
+		var ids []string
+		if existing != nil {
+			if err := json.Unmarshal(existing, &ids); err != nil {
+				return nil, errors.Wrapf(err, "Unable to parse the signature index for %s.", key)
+			}
+		}
+
+		for _, id := range ids {
+			if id == snapshotID {
+				return existing, nil
+			}
+		}
+		ids = append(ids, snapshotID)
+
+		blob, err := json.Marshal(ids)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to serialize the signature index for %s.", key)
+		}
+		return blob, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Unable to update the signature index for %s.", key)
+	}
+	return nil
+}